@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// blobPrefix is where deduplicated PDF content is stored, keyed by its
+// SHA-256 hash rather than the name a user uploaded it under.
+const blobPrefix = "blobs/"
+
+// bookPointer is the small object written at a user's own path; it never
+// holds PDF bytes itself, just a reference to the shared blob.
+type bookPointer struct {
+	BlobKey  string `json:"blob_key"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+func blobKeyForHash(sum string) string {
+	return blobPrefix + sum
+}
+
+// hashToTemp streams r through SHA-256 into a temp file, so the hash can be
+// known (and checked against existing blobs) before deciding whether to
+// upload the content at all. The caller must close and remove the file.
+func hashToTemp(r io.Reader) (*os.File, string, error) {
+	tmp, err := os.CreateTemp("", "upload-*.pdf")
+	if err != nil {
+		return nil, "", err
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(r, hasher)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+
+	return tmp, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writePointer stores a bookPointer referencing blobKey at key.
+func writePointer(ctx context.Context, storage StorageBackend, key string, p bookPointer) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Upload(ctx, key, bytes.NewReader(data), "application/json")
+	return err
+}
+
+// readPointer loads and parses the bookPointer stored at key.
+func readPointer(ctx context.Context, storage StorageBackend, key string) (*bookPointer, error) {
+	r, err := storage.Download(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var p bookPointer
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path"
+	"time"
 
 	"log"
 	"net/http"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-contrib/cors"
@@ -15,6 +18,14 @@ import (
 	"github.com/joho/godotenv"
 )
 
+const (
+	// uploadTimeoutBase is the minimum time allotted to an upload request.
+	uploadTimeoutBase = 30 * time.Second
+	// uploadThroughputBytesPerSec estimates a conservative client upload
+	// speed, used to size the per-request timeout off the file size.
+	uploadThroughputBytesPerSec = 1 * 1024 * 1024
+)
+
 // Define FileUploader interface
 type FileUploader interface {
 	Upload(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
@@ -47,11 +58,15 @@ func (ua *UploaderAdapter) Upload(ctx context.Context, input *s3.PutObjectInput)
 }
 
 var (
-	s3Client   *s3.Client
-	uploader   FileUploader // Use FileUploader interface here
-	bucketName = "books-uploaded"
+	bucketName     = "books-uploaded"
+	storageBackend StorageBackend
+	thumbnails     *thumbnailPipeline
 )
 
+// placeholderThumbnailURL is served for books whose thumbnail hasn't been
+// rendered yet (or failed to render).
+const placeholderThumbnailURL = "/static/thumbnail-placeholder.png"
+
 func main() {
 	// Load environment variables
 	err := godotenv.Load()
@@ -59,33 +74,70 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Initialize AWS S3 Client
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	// Pick the storage backend via STORAGE_PROVIDER (s3, disk, or gcs), so
+	// the server can run without AWS credentials in dev/test.
+	storageBackend, err = newStorageBackend(context.TODO())
 	if err != nil {
-		log.Fatalf("Error initializing AWS config: %v", err)
+		log.Fatalf("Error initializing storage backend: %v", err)
 	}
 
-	// Assign the initialized client
-	s3Client = s3.NewFromConfig(cfg)
-	// Initialize uploader using the manager.Uploader and wrap it with the UploaderAdapter
-	uploader = NewUploaderAdapter(manager.NewUploader(s3Client))
+	// Render thumbnails on a bounded worker pool, and backfill any PDFs
+	// uploaded before a thumbnail pipeline existed.
+	thumbnails = startThumbnailPipeline(context.Background(), storageBackend)
+	go reconcileThumbnails(context.Background(), storageBackend, thumbnails)
 
 	// Setup Gin app
 	r := gin.Default()
 	r.Use(cors.Default())
 
-	// Routes for API endpoints
-	r.POST("/upload", func(c *gin.Context) {
-		handleUpload(c, uploader) // Pass the uploader here (whether mock or real)
+	// Serves placeholderThumbnailURL (among any other static assets), so the
+	// fallback showLibrary returns for books without a rendered thumbnail
+	// actually resolves instead of 404ing.
+	r.Static("/static", "./static")
+
+	// Backends that need extra routes (e.g. the disk backend serving
+	// uploaded files) register them here.
+	if registrar, ok := storageBackend.(interface{ RegisterRoutes(*gin.Engine) }); ok {
+		registrar.RegisterRoutes(r)
+	}
+
+	// Routes for API endpoints. Anything that reads or writes a user's
+	// books is behind authMiddleware, which verifies the bearer JWT and
+	// supplies the userId handlers use instead of trusting client input.
+	authed := r.Group("/", authMiddleware())
+	authed.POST("/upload", func(c *gin.Context) {
+		handleUpload(c, storageBackend)
 	})
-	r.GET("/library", showLibrary)
+	authed.GET("/library", showLibrary)
+	authed.GET("/book/:id/url", handleBookURL)
+	authed.GET("/upload/status/:id", handleUploadStatus)
+
+	admin := r.Group("/", authMiddleware(), adminMiddleware())
+	admin.POST("/regenerate-thumbnail", func(c *gin.Context) {
+		handleRegenerateThumbnail(c, thumbnails)
+	})
+
+	// Resumable (tus-style) uploads are backed directly by S3 multipart, so
+	// they're only available when running against the S3 storage backend.
+	if s3b, ok := storageBackend.(*S3Backend); ok {
+		resumable, err := newResumableManager(s3b.client, s3b.bucket)
+		if err != nil {
+			log.Fatalf("Error initializing resumable upload manager: %v", err)
+		}
+		go resumable.runJanitor(context.Background())
+
+		authed.POST("/uploads", func(c *gin.Context) { handleCreateResumableUpload(c, resumable) })
+		authed.PATCH("/uploads/:id", func(c *gin.Context) { handleAppendResumableUpload(c, resumable) })
+		authed.HEAD("/uploads/:id", func(c *gin.Context) { handleHeadResumableUpload(c, resumable) })
+		authed.DELETE("/uploads/:id", func(c *gin.Context) { handleAbortResumableUpload(c, resumable) })
+	}
 
 	// Start server
 	r.Run(":5003") // listen and serve on port 5000
 }
 
 // Handle file upload logic
-func handleUpload(c *gin.Context, uploader FileUploader) {
+func handleUpload(c *gin.Context, storage StorageBackend) {
 	// Get the uploaded file
 	file, err := c.FormFile("pdf")
 	if err != nil {
@@ -114,50 +166,131 @@ func handleUpload(c *gin.Context, uploader FileUploader) {
 	}
 	defer f.Close()
 
-	// Final file path with folder prefix
-	s3Key := s3Folder + file.Filename
+	// Final file path, confined to the authenticated user's own prefix
+	userID := userIDFromContext(c)
+	pointerKey := userScopedKey(userID, s3Folder+file.Filename+".json")
 
-	// Upload the actual file to S3
-	_, uploadErr := uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(s3Key),
-		Body:        f,
-		ACL:         "public-read",
-		ContentType: aws.String("application/pdf"),
-	})
-	if uploadErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
+	// Per-request timeout scaled off the file size, so large uploads get
+	// more time than the flat baseline without uploads hanging forever.
+	timeout := uploadTimeoutBase + time.Duration(file.Size/uploadThroughputBytesPerSec)*time.Second
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	// Hash the upload before deciding whether to store it: if another user
+	// already uploaded these exact bytes, skip re-uploading and just point
+	// at the existing blob.
+	tmp, sum, hashErr := hashToTemp(f)
+	if hashErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process file"})
 		return
 	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	// Construct the final file URL
-	fileURL := "https://" + bucketName + ".s3.amazonaws.com/" + s3Key
-	c.JSON(http.StatusOK, gin.H{"pdf_url": fileURL, "pdf_name": file.Filename})
-}
+	blobKey := blobKeyForHash(sum)
 
-// Show library with all uploaded PDFs
-func showLibrary(c *gin.Context) {
-	userId := c.Query("userId")
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing userId"})
+	// The client may generate its own id and send it as upload_id alongside
+	// the file, so it knows what to poll GET /upload/status/:id with before
+	// (or while) this request is still in flight; a server-generated id is
+	// only ever visible in the final response, by which point the upload it
+	// describes has already finished.
+	uploadID := c.PostForm("upload_id")
+	if uploadID == "" {
+		uploadID = newUploadID()
+	}
+
+	exists, existsErr := storage.Exists(ctx, blobKey)
+	if existsErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for existing upload"})
 		return
 	}
 
-	resp, err := s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucketName),
-		Prefix: aws.String("users/"+userId + "/"), // ✅ Limit search to user-specific folder
-	})
+	if !exists {
+		uploadProgress.start(uploadID, userID, file.Size)
+		body := &countingReader{r: tmp, id: uploadID}
 
+		_, uploadErr := storage.Upload(ctx, blobKey, body, "application/pdf")
+		uploadProgress.finish(uploadID, uploadErr)
+		if uploadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
+			return
+		}
+	}
 
+	if err := writePointer(ctx, storage, pointerKey, bookPointer{BlobKey: blobKey, Filename: file.Filename, Size: file.Size}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save book"})
+		return
+	}
+
+	thumbnails.enqueue(blobKey)
+
+	fileURL, presignErr := storage.PresignRead(ctx, blobKey)
+	if presignErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate download URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pdf_url": fileURL, "pdf_name": file.Filename, "upload_id": uploadID, "deduplicated": exists})
+}
+
+// userScopedKey confines a client-supplied relative path to the
+// authenticated user's own prefix, so a request can never read or write
+// another user's keys via a path containing "../" segments.
+func userScopedKey(userID, rest string) string {
+	return "users/" + userID + path.Clean("/"+rest)
+}
+
+// newUploadID returns a short random hex id used to key upload progress.
+func newUploadID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleUploadStatus reports how far an in-flight (or just-finished) upload
+// has progressed, by wrapping the request body in a counting io.Reader.
+// Scoped to the authenticated user: another user's upload id is reported as
+// not found rather than leaking its progress.
+func handleUploadStatus(c *gin.Context) {
+	progress, ok := uploadProgress.get(c.Param("id"), userIDFromContext(c))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown upload id"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+// Show library with all uploaded PDFs
+func showLibrary(c *gin.Context) {
+	userId := userIDFromContext(c)
+
+	objects, err := storageBackend.List(context.TODO(), "users/"+userId+"/") // ✅ Limit search to user-specific folder
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load library"})
 		return
 	}
 
 	var books []map[string]string
-	for _, item := range resp.Contents {
-		pdfURL := "https://" + bucketName + ".s3.amazonaws.com/" + *item.Key
-		thumbnailURL := "https://" + bucketName + ".s3.amazonaws.com/thumbnails/" + *item.Key + ".jpg"
+	for _, item := range objects {
+		pointer, err := readPointer(context.TODO(), storageBackend, item.Key)
+		if err != nil {
+			log.Printf("Failed to resolve pointer %s: %v", item.Key, err)
+			continue
+		}
+
+		pdfURL, err := storageBackend.PresignRead(context.TODO(), pointer.BlobKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load library"})
+			return
+		}
+		thumbnailURL := placeholderThumbnailURL
+		if exists, err := storageBackend.Exists(context.TODO(), thumbnailKey(pointer.BlobKey)); err == nil && exists {
+			thumbnailURL, err = storageBackend.PresignRead(context.TODO(), thumbnailKey(pointer.BlobKey))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load library"})
+				return
+			}
+		}
 
 		log.Printf("PDF URL: %s", pdfURL)
 		log.Printf("Thumbnail URL: %s", thumbnailURL)
@@ -165,9 +298,28 @@ func showLibrary(c *gin.Context) {
 		books = append(books, map[string]string{
 			"url":       pdfURL,
 			"thumbnail": thumbnailURL,
-			"name":      *item.Key,
+			"name":      pointer.Filename,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"books": books})
 }
+
+// handleBookURL returns a short-lived presigned GET URL for a single book
+// belonging to the authenticated user.
+func handleBookURL(c *gin.Context) {
+	pointerKey := userScopedKey(userIDFromContext(c), c.Param("id"))
+
+	pointer, err := readPointer(context.TODO(), storageBackend, pointerKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	url, err := storageBackend.PresignRead(context.TODO(), pointer.BlobKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate book URL"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
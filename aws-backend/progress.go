@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// UploadProgress reports how much of an in-flight upload has been received.
+type UploadProgress struct {
+	Total    int64  `json:"total"`
+	Uploaded int64  `json:"uploaded"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+	ownerID  string
+}
+
+// progressStore tracks UploadProgress for uploads in flight, keyed by the id
+// returned from handleUpload, so GET /upload/status/:id can poll it. Each
+// entry is tagged with the uploading user so a different user's request
+// for the same id can be rejected.
+type progressStore struct {
+	mu   sync.Mutex
+	byID map[string]*UploadProgress
+}
+
+var uploadProgress = &progressStore{byID: make(map[string]*UploadProgress)}
+
+func (s *progressStore) start(id, ownerID string, total int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID[id] = &UploadProgress{Total: total, ownerID: ownerID}
+}
+
+func (s *progressStore) add(id string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.byID[id]; ok {
+		p.Uploaded += n
+	}
+}
+
+func (s *progressStore) finish(id string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byID[id]
+	if !ok {
+		return
+	}
+	p.Done = true
+	if err != nil {
+		p.Error = err.Error()
+	}
+}
+
+// get returns the progress for id, scoped to ownerID: a caller that isn't
+// the upload's owner gets the same "not found" result as an unknown id.
+func (s *progressStore) get(id, ownerID string) (UploadProgress, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byID[id]
+	if !ok || p.ownerID != ownerID {
+		return UploadProgress{}, false
+	}
+	return *p, true
+}
+
+// countingReader wraps an io.Reader and reports every read to a progress
+// entry, so upload progress can be served without buffering the body.
+type countingReader struct {
+	r  io.Reader
+	id string
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		uploadProgress.add(c.id, int64(n))
+	}
+	return n, err
+}
+
+// Seek delegates to the wrapped reader when it supports seeking, so retries
+// that rewind the body (see retryUpload) still work through the counter.
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := c.r.(io.Seeker)
+	if !ok {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return seeker.Seek(offset, whence)
+}
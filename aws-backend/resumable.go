@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// resumableMetaDirEnv overrides where resumable-upload metadata is
+	// persisted, so an upload can be resumed across a server restart.
+	resumableMetaDirEnv = "RESUMABLE_META_DIR"
+	// resumableIdleTimeout is how long an upload can sit untouched before
+	// the janitor aborts it and frees the S3 multipart parts.
+	resumableIdleTimeout = 24 * time.Hour
+	// resumableJanitorInterval is how often the janitor sweeps for idle uploads.
+	resumableJanitorInterval = time.Hour
+	// resumablePartMinSize is S3's minimum multipart part size for every part
+	// but the last. Client chunks (e.g. over a flaky mobile connection) may
+	// arrive much smaller than this, so appendChunk buffers them until a
+	// part-sized chunk accumulates instead of uploading each one as-is.
+	resumablePartMinSize = 5 * 1024 * 1024
+)
+
+// resumableUpload is the persisted state for one tus-style resumable upload.
+type resumableUpload struct {
+	ID           string                `json:"id"`
+	OwnerID      string                `json:"owner_id"`
+	Key          string                `json:"key"`
+	S3UploadID   string                `json:"s3_upload_id"`
+	TotalSize    int64                 `json:"total_size"`
+	Offset       int64                 `json:"offset"`
+	Parts        []types.CompletedPart `json:"parts"`
+	LastActivity time.Time             `json:"last_activity"`
+}
+
+// resumableManager implements the tus-style resumable upload protocol
+// (POST /uploads, PATCH /uploads/:id, HEAD /uploads/:id, DELETE /uploads/:id)
+// on top of S3 multipart upload, persisting the S3 UploadId and part ETags
+// so a client can resume after a dropped connection.
+type resumableManager struct {
+	client  *s3.Client
+	bucket  string
+	metaDir string
+
+	mu      sync.Mutex
+	uploads map[string]*resumableUpload
+	// pending buffers bytes appended since the last full part was flushed to
+	// S3, keyed by upload id. It's in-memory only: u.Offset only advances
+	// once bytes are actually flushed, so a restart just makes the client
+	// resend whatever was still buffered rather than losing data silently.
+	pending map[string]*bytes.Buffer
+}
+
+func newResumableManager(client *s3.Client, bucket string) (*resumableManager, error) {
+	dir := os.Getenv(resumableMetaDirEnv)
+	if dir == "" {
+		dir = "./data/uploads-meta"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	m := &resumableManager{
+		client:  client,
+		bucket:  bucket,
+		metaDir: dir,
+		uploads: make(map[string]*resumableUpload),
+		pending: make(map[string]*bytes.Buffer),
+	}
+	if err := m.loadAll(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *resumableManager) metaPath(id string) string {
+	return filepath.Join(m.metaDir, id+".json")
+}
+
+func (m *resumableManager) loadAll() error {
+	entries, err := os.ReadDir(m.metaDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(m.metaDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var u resumableUpload
+		if err := json.Unmarshal(data, &u); err != nil {
+			continue
+		}
+		m.uploads[u.ID] = &u
+	}
+	return nil
+}
+
+func (m *resumableManager) save(u *resumableUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.metaPath(u.ID), data, 0o644)
+}
+
+func (m *resumableManager) delete(id string) {
+	delete(m.uploads, id)
+	delete(m.pending, id)
+	os.Remove(m.metaPath(id))
+}
+
+// create starts a new resumable upload for key with the declared total size,
+// owned by ownerID so later PATCH/HEAD/DELETE calls can be scoped to it.
+func (m *resumableManager) create(ctx context.Context, ownerID, key string, totalSize int64) (*resumableUpload, error) {
+	out, err := m.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(m.bucket),
+		Key:         aws.String(key),
+		ACL:         "private",
+		ContentType: aws.String("application/pdf"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := &resumableUpload{
+		ID:           newUploadID(),
+		OwnerID:      ownerID,
+		Key:          key,
+		S3UploadID:   aws.ToString(out.UploadId),
+		TotalSize:    totalSize,
+		LastActivity: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.uploads[u.ID] = u
+	m.mu.Unlock()
+
+	return u, m.save(u)
+}
+
+// appendChunk buffers the bytes read from r for id, flushing full
+// resumablePartMinSize parts to S3 as they accumulate and finalizing the
+// multipart upload (flushing whatever remains as the short last part) once
+// the declared total size has arrived. Scoped to ownerID: an upload owned by
+// a different user is reported as not found rather than leaking its
+// existence.
+func (m *resumableManager) appendChunk(ctx context.Context, ownerID, id string, r io.Reader) (*resumableUpload, error) {
+	m.mu.Lock()
+	u, ok := m.uploads[id]
+	if !ok || u.OwnerID != ownerID {
+		m.mu.Unlock()
+		return nil, errUploadNotFound
+	}
+	buf := m.pending[id]
+	if buf == nil {
+		buf = &bytes.Buffer{}
+		m.pending[id] = buf
+	}
+	m.mu.Unlock()
+
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	u.LastActivity = time.Now()
+
+	for buf.Len() >= resumablePartMinSize && u.Offset+int64(buf.Len()) < u.TotalSize {
+		if err := m.flushPart(ctx, u, buf.Next(resumablePartMinSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.Offset+int64(buf.Len()) < u.TotalSize {
+		return u, m.save(u)
+	}
+
+	if buf.Len() > 0 {
+		if err := m.flushPart(ctx, u, buf.Next(buf.Len())); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := m.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(m.bucket),
+		Key:             aws.String(u.Key),
+		UploadId:        aws.String(u.S3UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: u.Parts},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.delete(id)
+	m.mu.Unlock()
+	return u, nil
+}
+
+// flushPart uploads data as the next S3 part for u, recording its
+// CompletedPart and advancing u.Offset only once it's durably in S3.
+func (m *resumableManager) flushPart(ctx context.Context, u *resumableUpload, data []byte) error {
+	partNumber := int32(len(u.Parts) + 1)
+	partOut, err := m.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(m.bucket),
+		Key:        aws.String(u.Key),
+		UploadId:   aws.String(u.S3UploadID),
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	u.Parts = append(u.Parts, types.CompletedPart{ETag: partOut.ETag, PartNumber: aws.Int32(partNumber)})
+	u.Offset += int64(len(data))
+	m.mu.Unlock()
+	return nil
+}
+
+// get returns the upload for id, scoped to ownerID: a caller that isn't the
+// upload's owner gets the same "not found" result as an unknown id.
+func (m *resumableManager) get(id, ownerID string) (*resumableUpload, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.uploads[id]
+	if !ok || u.OwnerID != ownerID {
+		return nil, false
+	}
+	return u, true
+}
+
+// abort cancels an in-progress resumable upload and releases its S3 parts.
+// Scoped to ownerID; pass "" from the janitor, which aborts by id alone.
+func (m *resumableManager) abort(ctx context.Context, ownerID, id string) error {
+	m.mu.Lock()
+	u, ok := m.uploads[id]
+	m.mu.Unlock()
+	if !ok || (ownerID != "" && u.OwnerID != ownerID) {
+		return errUploadNotFound
+	}
+
+	_, err := m.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(m.bucket),
+		Key:      aws.String(u.Key),
+		UploadId: aws.String(u.S3UploadID),
+	})
+
+	m.mu.Lock()
+	m.delete(id)
+	m.mu.Unlock()
+	return err
+}
+
+// runJanitor periodically aborts resumable uploads that have been idle for
+// longer than resumableIdleTimeout, so orphaned parts don't accrue S3 storage
+// charges forever.
+func (m *resumableManager) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(resumableJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sweepIdle(ctx)
+		}
+	}
+}
+
+func (m *resumableManager) sweepIdle(ctx context.Context) {
+	m.mu.Lock()
+	var idle []string
+	for id, u := range m.uploads {
+		if time.Since(u.LastActivity) > resumableIdleTimeout {
+			idle = append(idle, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range idle {
+		if err := m.abort(ctx, "", id); err != nil {
+			log.Printf("janitor: failed to abort idle upload %s: %v", id, err)
+		}
+	}
+}
+
+var errUploadNotFound = &uploadNotFoundError{}
+
+type uploadNotFoundError struct{}
+
+func (*uploadNotFoundError) Error() string { return "upload not found" }
+
+// --- HTTP handlers ---
+
+func handleCreateResumableUpload(c *gin.Context, m *resumableManager) {
+	var req struct {
+		Key  string `json:"key"`
+		Size int64  `json:"size"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Key == "" || req.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing key or size"})
+		return
+	}
+
+	userID := userIDFromContext(c)
+	key := userScopedKey(userID, req.Key)
+
+	u, err := m.create(c.Request.Context(), userID, key, req.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": u.ID, "offset": u.Offset})
+}
+
+func handleAppendResumableUpload(c *gin.Context, m *resumableManager) {
+	id := c.Param("id")
+	if c.Request.ContentLength <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing Content-Length"})
+		return
+	}
+
+	u, err := m.appendChunk(c.Request.Context(), userIDFromContext(c), id, c.Request.Body)
+	if err == errUploadNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown upload id"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to append chunk"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offset": u.Offset, "total": u.TotalSize})
+}
+
+func handleHeadResumableUpload(c *gin.Context, m *resumableManager) {
+	u, ok := m.get(c.Param("id"), userIDFromContext(c))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.TotalSize, 10))
+	c.Status(http.StatusOK)
+}
+
+func handleAbortResumableUpload(c *gin.Context, m *resumableManager) {
+	err := m.abort(c.Request.Context(), userIDFromContext(c), c.Param("id"))
+	if err == errUploadNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown upload id"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort upload"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	maxUploadRetries  = 3
+	retryBaseDelay    = 500 * time.Millisecond
+	retryTransientMax = 8 * time.Second
+)
+
+// retryUpload runs fn, retrying with exponential backoff when the error
+// looks transient (request timeouts, throttling, 5xx from S3). Context
+// cancellation/deadline errors are never retried.
+func retryUpload(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= maxUploadRetries; attempt++ {
+		err = fn()
+		if err == nil || !isRetryableUploadError(err) {
+			return err
+		}
+
+		delay := time.Duration(math.Min(
+			float64(retryBaseDelay)*math.Pow(2, float64(attempt)),
+			float64(retryTransientMax),
+		))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
+
+// isRetryableUploadError reports whether err is a transient S3 error worth
+// retrying (RequestTimeout, SlowDown, or a 5xx response).
+func isRetryableUploadError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "RequestTimeout", "SlowDown", "RequestTimeTooSkewed":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() >= 500
+	}
+
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// StorageObject describes a single object returned by a backend listing.
+type StorageObject struct {
+	Key  string
+	Size int64
+}
+
+// StorageBackend abstracts the object store used to persist and serve
+// uploaded PDFs (and their thumbnails), so handleUpload/showLibrary don't
+// depend on a concrete S3 client. Selected at startup via STORAGE_PROVIDER.
+type StorageBackend interface {
+	// Upload stores the contents of r under key and returns the URL clients
+	// should use to fetch it back.
+	Upload(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]StorageObject, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// PresignRead returns a URL usable to read key back.
+	PresignRead(ctx context.Context, key string) (string, error)
+	// Exists reports whether an object is stored under key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Download returns a reader over the object stored under key. The
+	// caller must close it.
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// newStorageBackend builds the backend selected by STORAGE_PROVIDER
+// (s3, disk, or gcs). It defaults to s3 to match existing deployments.
+func newStorageBackend(ctx context.Context) (StorageBackend, error) {
+	switch provider := os.Getenv("STORAGE_PROVIDER"); provider {
+	case "", "s3":
+		return newS3Backend(ctx)
+	case "disk":
+		return newDiskBackend()
+	case "gcs":
+		return newGCSBackend(ctx)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_PROVIDER %q (want s3, disk, or gcs)", provider)
+	}
+}
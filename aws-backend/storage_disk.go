@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diskRoot is where the disk backend stores uploaded files; overridable so
+// tests/dev environments don't collide with each other.
+const diskRootEnv = "DISK_STORAGE_ROOT"
+
+// DiskBackend is a local-filesystem StorageBackend for dev and testing, so
+// the server can run without AWS credentials. Files are served back over
+// HTTP via the /files/* route registered in RegisterRoutes.
+type DiskBackend struct {
+	root string
+}
+
+func newDiskBackend() (*DiskBackend, error) {
+	root := os.Getenv(diskRootEnv)
+	if root == "" {
+		root = "./data"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskBackend{root: root}, nil
+}
+
+// RegisterRoutes exposes the stored files at /files/*key. main wires this up
+// for backends that implement it (disk only; S3/GCS serve via their own URLs).
+func (b *DiskBackend) RegisterRoutes(r *gin.Engine) {
+	r.Static("/files", b.root)
+}
+
+func (b *DiskBackend) Upload(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	path := filepath.Join(b.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return "", err
+	}
+	return b.publicURL(key), nil
+}
+
+func (b *DiskBackend) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	err := filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		key := filepath.ToSlash(strings.TrimPrefix(path, b.root+string(os.PathSeparator)))
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, StorageObject{Key: key, Size: info.Size()})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func (b *DiskBackend) Delete(ctx context.Context, key string) error {
+	return os.Remove(filepath.Join(b.root, filepath.FromSlash(key)))
+}
+
+func (b *DiskBackend) PresignRead(ctx context.Context, key string) (string, error) {
+	return b.publicURL(key), nil
+}
+
+func (b *DiskBackend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(b.root, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *DiskBackend) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(b.root, filepath.FromSlash(key)))
+}
+
+func (b *DiskBackend) publicURL(key string) string {
+	return "/files/" + key
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestDiskBackend(t *testing.T) *DiskBackend {
+	t.Helper()
+	t.Setenv(diskRootEnv, t.TempDir())
+
+	b, err := newDiskBackend()
+	if err != nil {
+		t.Fatalf("newDiskBackend: %v", err)
+	}
+	return b
+}
+
+func TestDiskBackendUploadDownloadExists(t *testing.T) {
+	b := newTestDiskBackend(t)
+	ctx := context.Background()
+
+	const key = "users/u1/book.pdf"
+
+	if exists, err := b.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists before upload = %v, %v; want false, nil", exists, err)
+	}
+
+	url, err := b.Upload(ctx, key, strings.NewReader("pdf-bytes"), "application/pdf")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if url != "/files/"+key {
+		t.Errorf("Upload url = %q, want %q", url, "/files/"+key)
+	}
+
+	if exists, err := b.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after upload = %v, %v; want true, nil", exists, err)
+	}
+
+	r, err := b.Download(ctx, key)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "pdf-bytes" {
+		t.Errorf("Download content = %q, want %q", got, "pdf-bytes")
+	}
+}
+
+func TestDiskBackendListScopedToPrefix(t *testing.T) {
+	b := newTestDiskBackend(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"users/u1/a.pdf", "users/u1/b.pdf", "users/u2/c.pdf"} {
+		if _, err := b.Upload(ctx, key, strings.NewReader("x"), "application/pdf"); err != nil {
+			t.Fatalf("Upload(%s): %v", key, err)
+		}
+	}
+
+	objects, err := b.List(ctx, "users/u1/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("List(users/u1/) returned %d objects, want 2: %+v", len(objects), objects)
+	}
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Key, "users/u1/") {
+			t.Errorf("List returned object outside prefix: %+v", obj)
+		}
+	}
+}
+
+func TestDiskBackendDelete(t *testing.T) {
+	b := newTestDiskBackend(t)
+	ctx := context.Background()
+
+	const key = "users/u1/book.pdf"
+	if _, err := b.Upload(ctx, key, strings.NewReader("x"), "application/pdf"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if err := b.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if exists, err := b.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after delete = %v, %v; want false, nil", exists, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSBackend is a Google Cloud Storage StorageBackend, for deployments that
+// prefer Firebase/GCS over S3.
+type GCSBackend struct {
+	client *storage.Client
+	bucket string
+}
+
+func newGCSBackend(ctx context.Context) (*GCSBackend, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := os.Getenv("GCS_BUCKET")
+	if bucket == "" {
+		bucket = bucketName
+	}
+	return &GCSBackend{client: client, bucket: bucket}, nil
+}
+
+func (b *GCSBackend) Upload(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	w := b.client.Bucket(b.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return b.publicURL(key), nil
+}
+
+func (b *GCSBackend) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	it := b.client.Bucket(b.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, StorageObject{Key: attrs.Name, Size: attrs.Size})
+	}
+	return objects, nil
+}
+
+func (b *GCSBackend) Delete(ctx context.Context, key string) error {
+	return b.client.Bucket(b.bucket).Object(key).Delete(ctx)
+}
+
+func (b *GCSBackend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := b.client.Bucket(b.bucket).Object(key).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *GCSBackend) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.client.Bucket(b.bucket).Object(key).NewReader(ctx)
+}
+
+func (b *GCSBackend) PresignRead(ctx context.Context, key string) (string, error) {
+	opts := &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(15 * time.Minute),
+	}
+	return b.client.Bucket(b.bucket).SignedURL(key, opts)
+}
+
+func (b *GCSBackend) publicURL(key string) string {
+	return "https://storage.googleapis.com/" + b.bucket + "/" + key
+}
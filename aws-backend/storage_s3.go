@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// uploadPartSize is the multipart chunk size handed to manager.Uploader.
+	uploadPartSize = 16 * 1024 * 1024
+	// uploadPartConcurrency bounds how many parts of a single upload run at once.
+	uploadPartConcurrency = 5
+	// maxConcurrentUploads bounds how many client uploads run at once across
+	// the whole process, so many simultaneous uploads don't exhaust file
+	// descriptors or trip "context deadline exceeded" errors.
+	maxConcurrentUploads = 20
+	// presignedURLTTL is how long a presigned GET URL remains valid.
+	presignedURLTTL = 15 * time.Minute
+)
+
+// S3Backend is the default StorageBackend, backed by an S3-compatible bucket.
+// Objects are written private; reads go through short-lived presigned URLs
+// instead of public-read ACLs.
+type S3Backend struct {
+	client   *s3.Client
+	presign  *s3.PresignClient
+	uploader FileUploader
+	bucket   string
+	sem      chan struct{}
+}
+
+// newS3Backend loads AWS config from the environment and wires up the
+// existing uploader/client pair used by the rest of the package.
+func newS3Backend(ctx context.Context) (*S3Backend, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg)
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = uploadPartSize
+		u.Concurrency = uploadPartConcurrency
+		u.LeavePartsOnError = false // abort the multipart upload instead of leaving orphaned parts
+	})
+	return &S3Backend{
+		client:   client,
+		presign:  s3.NewPresignClient(client),
+		uploader: NewUploaderAdapter(uploader),
+		bucket:   bucketName,
+		sem:      make(chan struct{}, maxConcurrentUploads),
+	}, nil
+}
+
+func (b *S3Backend) Upload(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	select {
+	case b.sem <- struct{}{}:
+		defer func() { <-b.sem }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	seeker, seekable := r.(io.Seeker)
+	var startOffset int64
+	if seekable {
+		startOffset, _ = seeker.Seek(0, io.SeekCurrent)
+	}
+
+	err := retryUpload(ctx, func() error {
+		if seekable {
+			if _, err := seeker.Seek(startOffset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		_, err := b.uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(b.bucket),
+			Key:         aws.String(key),
+			Body:        r,
+			ACL:         "private",
+			ContentType: aws.String(contentType),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return b.PresignRead(ctx, key)
+}
+
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	resp, err := b.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]StorageObject, 0, len(resp.Contents))
+	for _, item := range resp.Contents {
+		objects = append(objects, StorageObject{Key: *item.Key, Size: aws.ToInt64(item.Size)})
+	}
+	return objects, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *S3Backend) PresignRead(ctx context.Context, key string) (string, error) {
+	req, err := b.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(presignedURLTTL))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (b *S3Backend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *S3Backend) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
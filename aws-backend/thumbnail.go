@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// thumbnailWorkers is the number of goroutines rendering thumbnails
+	// concurrently from the bounded job queue.
+	thumbnailWorkers = 4
+	// thumbnailQueueSize bounds how many pending thumbnail jobs can be
+	// buffered before enqueueThumbnail starts dropping them.
+	thumbnailQueueSize = 256
+)
+
+// thumbnailKey returns the S3 key a PDF's thumbnail is stored under.
+func thumbnailKey(pdfKey string) string {
+	return "thumbnails/" + pdfKey + ".jpg"
+}
+
+// thumbnailPipeline renders page-1 JPEG thumbnails for uploaded PDFs on a
+// bounded worker pool, so a burst of uploads can't spawn unbounded pdftoppm
+// processes.
+type thumbnailPipeline struct {
+	storage StorageBackend
+	jobs    chan string
+}
+
+func startThumbnailPipeline(ctx context.Context, storage StorageBackend) *thumbnailPipeline {
+	p := &thumbnailPipeline{
+		storage: storage,
+		jobs:    make(chan string, thumbnailQueueSize),
+	}
+	for i := 0; i < thumbnailWorkers; i++ {
+		go p.worker(ctx)
+	}
+	return p
+}
+
+func (p *thumbnailPipeline) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-p.jobs:
+			if err := p.render(ctx, key); err != nil {
+				log.Printf("thumbnail: failed to render %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// enqueue schedules key for thumbnail generation. It never blocks: if the
+// queue is full the job is dropped and logged, rather than stalling a
+// request handler.
+func (p *thumbnailPipeline) enqueue(key string) {
+	select {
+	case p.jobs <- key:
+	default:
+		log.Printf("thumbnail: queue full, dropping job for %s", key)
+	}
+}
+
+// render downloads the PDF for key, renders its first page to a JPEG via
+// pdftoppm, and uploads the result to thumbnails/<key>.jpg.
+func (p *thumbnailPipeline) render(ctx context.Context, key string) error {
+	tmpDir, err := os.MkdirTemp("", "thumbnail-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pdfPath := filepath.Join(tmpDir, "source.pdf")
+	if err := p.downloadTo(ctx, key, pdfPath); err != nil {
+		return err
+	}
+
+	outPrefix := filepath.Join(tmpDir, "thumb")
+	cmd := exec.CommandContext(ctx, "pdftoppm", "-jpeg", "-f", "1", "-l", "1", "-singlefile", pdfPath, outPrefix)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pdftoppm: %w (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	f, err := os.Open(outPrefix + ".jpg")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = p.storage.Upload(ctx, thumbnailKey(key), f, "image/jpeg")
+	return err
+}
+
+func (p *thumbnailPipeline) downloadTo(ctx context.Context, key, destPath string) error {
+	src, err := p.storage.Download(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+// reconcileThumbnails scans the bucket at startup for deduplicated blobs that
+// are missing a thumbnail and enqueues them, so thumbnails added before this
+// pipeline existed (or lost to a failed render) eventually get regenerated.
+func reconcileThumbnails(ctx context.Context, storage StorageBackend, pipeline *thumbnailPipeline) {
+	objects, err := storage.List(ctx, blobPrefix)
+	if err != nil {
+		log.Printf("thumbnail: reconciliation list failed: %v", err)
+		return
+	}
+
+	for _, obj := range objects {
+		exists, err := storage.Exists(ctx, thumbnailKey(obj.Key))
+		if err != nil {
+			log.Printf("thumbnail: reconciliation check failed for %s: %v", obj.Key, err)
+			continue
+		}
+		if !exists {
+			pipeline.enqueue(obj.Key)
+		}
+	}
+}
+
+// handleRegenerateThumbnail is an admin endpoint that re-enqueues thumbnail
+// generation for a single key, e.g. after a bad render.
+func handleRegenerateThumbnail(c *gin.Context, pipeline *thumbnailPipeline) {
+	var req struct {
+		Key string `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing key"})
+		return
+	}
+	pipeline.enqueue(req.Key)
+	c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+}